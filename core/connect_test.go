@@ -0,0 +1,242 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestInteractivePTY起一个支持pty-req/shell/window-change的内存ssh server:
+// shell只是把收到的输入原样回显为输出,足够用来验证Connect()对WSMsg各Type的分发逻辑,
+// 不需要一个真正的shell。每次window-change请求的rows/cols会被推入windowChanges供断言
+func newTestInteractivePTY(t *testing.T) (client *ssh.Client, windowChanges chan [2]uint32) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	signer, err := ssh.NewSignerFromSigner(priv)
+	if err != nil {
+		t.Fatalf("NewSignerFromSigner: %v", err)
+	}
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	windowChanges = make(chan [2]uint32, 8)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		sconn, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		for newChannel := range chans {
+			if newChannel.ChannelType() != "session" {
+				newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+				continue
+			}
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go func(channel ssh.Channel, requests <-chan *ssh.Request) {
+				for req := range requests {
+					switch req.Type {
+					case "pty-req":
+						if req.WantReply {
+							req.Reply(true, nil)
+						}
+					case "shell":
+						if req.WantReply {
+							req.Reply(true, nil)
+						}
+						go io.Copy(channel, channel) // 把输入原样回显为输出
+					case "window-change":
+						if len(req.Payload) >= 16 {
+							var cols, rows uint32
+							cols = uint32(req.Payload[0])<<24 | uint32(req.Payload[1])<<16 | uint32(req.Payload[2])<<8 | uint32(req.Payload[3])
+							rows = uint32(req.Payload[4])<<24 | uint32(req.Payload[5])<<16 | uint32(req.Payload[6])<<8 | uint32(req.Payload[7])
+							windowChanges <- [2]uint32{rows, cols}
+						}
+						if req.WantReply {
+							req.Reply(true, nil)
+						}
+					default:
+						if req.WantReply {
+							req.Reply(false, nil)
+						}
+					}
+				}
+			}(channel, requests)
+		}
+		sconn.Wait()
+	}()
+
+	client, err = ssh.Dial("tcp", ln.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		Auth:            []ssh.AuthMethod{ssh.Password("x")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, windowChanges
+}
+
+// newConnectTestWS起一个真正的http+ws server,把Connect()接收到的每条消息都喂给回调,
+// 返回供测试驱动的客户端*websocket.Conn
+func newConnectTestWS(t *testing.T, onConnect func(*websocket.Conn)) (*websocket.Conn, func()) {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		onConnect(conn)
+	}))
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		srv.Close()
+		t.Fatalf("dial: %v", err)
+	}
+	return conn, func() {
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func sendWSMsg(t *testing.T, conn *websocket.Conn, msg WSMsg) {
+	t.Helper()
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, b); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+}
+
+// readUntilStdoutContains漂过ping之类不产生输出的消息,直到收到一条包含want的stdout帧
+func readUntilStdoutContains(t *testing.T, conn *websocket.Conn, want string) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		_, p, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		var msg WSMsg
+		if err := json.Unmarshal(p, &msg); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if msg.Type == MsgTypeStdout && strings.Contains(msg.Data, want) {
+			return
+		}
+	}
+}
+
+func TestConnect_CmdMessageEchoedBackAsStdout(t *testing.T) {
+	client, _ := newTestInteractivePTY(t)
+	sclient := &SSHClient{Client: client}
+	if sclient.InitTerminal(24, 80) == nil {
+		t.Fatal("InitTerminal failed")
+	}
+
+	// Connect内部只起goroutine就返回,不会阻塞到会话结束,这里直接在onConnect里调用它
+	clientConn, cleanup := newConnectTestWS(t, func(serverSideConn *websocket.Conn) {
+		sclient.Connect(serverSideConn, 5*time.Second)
+	})
+	defer cleanup()
+
+	sendWSMsg(t, clientConn, WSMsg{Type: MsgTypeCmd, Data: base64.StdEncoding.EncodeToString([]byte("echo-me\r"))})
+	readUntilStdoutContains(t, clientConn, "echo-me")
+}
+
+func TestConnect_ResizeMessageTriggersWindowChange(t *testing.T) {
+	client, windowChanges := newTestInteractivePTY(t)
+	sclient := &SSHClient{Client: client}
+	if sclient.InitTerminal(24, 80) == nil {
+		t.Fatal("InitTerminal failed")
+	}
+
+	clientConn, cleanup := newConnectTestWS(t, func(serverSideConn *websocket.Conn) {
+		sclient.Connect(serverSideConn, 5*time.Second)
+	})
+	defer cleanup()
+
+	sendWSMsg(t, clientConn, WSMsg{Type: MsgTypeResize, Rows: 50, Cols: 120})
+
+	select {
+	case got := <-windowChanges:
+		if got != [2]uint32{50, 120} {
+			t.Fatalf("window-change got rows=%d cols=%d, want rows=50 cols=120", got[0], got[1])
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for a window-change request")
+	}
+}
+
+func TestConnect_PingMessageIsIgnored(t *testing.T) {
+	client, _ := newTestInteractivePTY(t)
+	sclient := &SSHClient{Client: client}
+	if sclient.InitTerminal(24, 80) == nil {
+		t.Fatal("InitTerminal failed")
+	}
+
+	clientConn, cleanup := newConnectTestWS(t, func(serverSideConn *websocket.Conn) {
+		sclient.Connect(serverSideConn, 5*time.Second)
+	})
+	defer cleanup()
+
+	sendWSMsg(t, clientConn, WSMsg{Type: MsgTypePing})
+	// a ping must not close the session or produce an error frame; confirm the
+	// connection is still usable by exercising a real cmd round-trip right after
+	sendWSMsg(t, clientConn, WSMsg{Type: MsgTypeCmd, Data: base64.StdEncoding.EncodeToString([]byte("still-alive\r"))})
+	readUntilStdoutContains(t, clientConn, "still-alive")
+}
+
+func TestConnect_CloseMessageEndsTheSession(t *testing.T) {
+	client, _ := newTestInteractivePTY(t)
+	sclient := &SSHClient{Client: client}
+	if sclient.InitTerminal(24, 80) == nil {
+		t.Fatal("InitTerminal failed")
+	}
+
+	clientConn, cleanup := newConnectTestWS(t, func(serverSideConn *websocket.Conn) {
+		sclient.Connect(serverSideConn, 5*time.Second)
+	})
+	defer cleanup()
+
+	sendWSMsg(t, clientConn, WSMsg{Type: MsgTypeClose})
+
+	// the server side closes the underlying connection once stopCh fires;
+	// the client-side read must observe that instead of timing out
+	clientConn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, _, err := clientConn.ReadMessage(); err == nil {
+		t.Fatal("expected the connection to be closed after a close message")
+	}
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gopkg.in/yaml.v3"
+)
+
+// FilterPolicy决定deny-list命中一行命令后的处理方式
+type FilterPolicy string
+
+const (
+	// PolicyDrop 丢弃这一行,不转发给远程shell,并向前端推送一条警告
+	PolicyDrop FilterPolicy = "drop"
+	// PolicyFlag 放行这一行,但把会话标记为IsFlagged,交给人工复核
+	PolicyFlag FilterPolicy = "flag"
+)
+
+// CommandLogEntry是一条审计日志记录,写给Logger供管理员追踪谁在哪台主机上执行了什么
+type CommandLogEntry struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Host    string    `json:"host"`
+	Cmdline string    `json:"cmdline"`
+}
+
+// CommandFilter 是一个可插拔的命令审计/拦截器,应用在写往StdinPipe的goroutine上
+type CommandFilter struct {
+	DenyList []*regexp.Regexp
+	Policy   FilterPolicy
+	Logger   io.Writer
+}
+
+// commandFilterConfig 是deny-list配置文件(YAML/JSON)的结构
+type commandFilterConfig struct {
+	Patterns []string `json:"patterns" yaml:"patterns"`
+	Policy   string   `json:"policy" yaml:"policy"`
+}
+
+// NewCommandFilter 用编译好的deny-list正则和处理策略构造CommandFilter
+func NewCommandFilter(patterns []string, policy FilterPolicy, logger io.Writer) (*CommandFilter, error) {
+	denyList := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deny-list pattern %q: %w", pattern, err)
+		}
+		denyList = append(denyList, re)
+	}
+	return &CommandFilter{DenyList: denyList, Policy: policy, Logger: logger}, nil
+}
+
+// LoadCommandFilter 从YAML或JSON配置文件(按扩展名判断)加载deny-list和策略
+func LoadCommandFilter(configPath string, logger io.Writer) (*CommandFilter, error) {
+	raw, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var cfg commandFilterConfig
+	switch filepath.Ext(configPath) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &cfg)
+	default:
+		err = json.Unmarshal(raw, &cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	policy := FilterPolicy(cfg.Policy)
+	if policy == "" {
+		policy = PolicyDrop
+	}
+	return NewCommandFilter(cfg.Patterns, policy, logger)
+}
+
+// Check 返回cmdline是否命中了deny-list
+func (f *CommandFilter) Check(cmdline string) bool {
+	for _, re := range f.DenyList {
+		if re.MatchString(cmdline) {
+			return true
+		}
+	}
+	return false
+}
+
+// LogCommand 把一条命令写入审计日志sink,Logger为nil时跳过
+func (f *CommandFilter) LogCommand(entry CommandLogEntry) {
+	if f.Logger == nil {
+		return
+	}
+	fmt.Fprintf(f.Logger, "%s\t%s\t%s\t%s\n",
+		entry.Time.Format(time.RFC3339), entry.User, entry.Host, entry.Cmdline)
+}
+
+// isControlSequence判断一段cmd数据是不是方向键/Ctrl-C/Tab补全这类控制序列而不是正在输入的命令文本:
+// 不含\r\n的前提下出现任何<0x20(ESC/Ctrl-C/Tab等)或0x7f(DEL)字节。
+// 这类数据必须立即转发,缓冲到\r/\n才转发会让终端失去实时交互能力
+func isControlSequence(data []byte) bool {
+	for _, b := range data {
+		if b == '\r' || b == '\n' {
+			return false
+		}
+	}
+	for _, b := range data {
+		if b < 0x20 || b == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// applyControlEdit把一个控制序列对"当前正在输入、尚未提交的那一行"的编辑效果应用到
+// inputFilterBuff/inputFilterCursor上。控制序列本身仍然立即原样转发给远程(见feedCmdInput),
+// 这里只是让本地用来过deny-list/写审计日志的那份行内容跟得上退格/Ctrl-U/左右方向键这些编辑操作——
+// 否则像"先输入rmf /,再用方向键回退插入 -r"这种情况,审计记录的仍然是按键的先后顺序拼接,
+// 而不是实际会被提交执行的那一行,deny-list就可能形同虚设。
+// 无法识别的控制序列(Tab补全、历史翻页、Home/End等)我们没法知道它对远程行内容的具体影响,
+// 保守地清空本地缓冲区,避免继续用一份可能已经失真的内容去做deny-list匹配。
+func (sclient *SSHClient) applyControlEdit(data []byte) {
+	buf, cursor := sclient.inputFilterBuff, sclient.inputFilterCursor
+	for i := 0; i < len(data); i++ {
+		switch b := data[i]; {
+		case b == 0x08 || b == 0x7f: // backspace / DEL
+			if cursor > 0 {
+				buf = append(buf[:cursor-1], buf[cursor:]...)
+				cursor--
+			}
+		case b == 0x15: // Ctrl-U: 清除光标之前的内容
+			buf = buf[cursor:]
+			cursor = 0
+		case b == 0x03: // Ctrl-C: 多数shell上会直接丢弃当前未提交的行
+			buf = buf[:0]
+			cursor = 0
+		case b == 0x1b && i+2 < len(data) && data[i+1] == '[' && data[i+2] == 'D': // 左方向键
+			if cursor > 0 {
+				cursor--
+			}
+			i += 2
+		case b == 0x1b && i+2 < len(data) && data[i+1] == '[' && data[i+2] == 'C': // 右方向键
+			if cursor < len(buf) {
+				cursor++
+			}
+			i += 2
+		default:
+			buf, cursor = nil, 0
+			sclient.inputFilterBuff, sclient.inputFilterCursor = buf, cursor
+			return
+		}
+	}
+	sclient.inputFilterBuff, sclient.inputFilterCursor = buf, cursor
+}
+
+// feedCmdInput 把一段cmd消息的明文应用到inputFilterBuff上,按\r或\n切出完整命令行逐条过审计,
+// 命中deny-list时按Policy丢弃该行(并推送一条warn帧)或仅标记IsFlagged,
+// 返回值为实际应当转发给远程shell的数据。
+// 方向键/Ctrl-C/Tab这类控制序列不经过这条"按行攒批"的逻辑,直接原样转发以保持终端实时响应,
+// 但仍然会通过applyControlEdit反映到inputFilterBuff里,见其注释。
+func (sclient *SSHClient) feedCmdInput(data []byte, ws *websocket.Conn) []byte {
+	if isControlSequence(data) {
+		sclient.applyControlEdit(data)
+		return data
+	}
+
+	forward := make([]byte, 0, len(data))
+	for _, b := range data {
+		if b == '\r' || b == '\n' {
+			cmdline := string(sclient.inputFilterBuff)
+			sclient.inputFilterBuff = sclient.inputFilterBuff[:0]
+			sclient.inputFilterCursor = 0
+
+			blocked := sclient.Filter.Check(cmdline)
+			sclient.Filter.LogCommand(CommandLogEntry{
+				Time:    time.Now(),
+				User:    sclient.Username,
+				Host:    sclient.IPAddress,
+				Cmdline: cmdline,
+			})
+			if blocked && sclient.Filter.Policy == PolicyDrop {
+				warnMsg, _ := json.Marshal(WSMsg{Type: MsgTypeWarn, Data: "command blocked"})
+				ws.WriteMessage(websocket.TextMessage, warnMsg)
+				continue
+			}
+			if blocked && sclient.Filter.Policy == PolicyFlag {
+				sclient.IsFlagged = true
+			}
+			forward = append(forward, []byte(cmdline)...)
+			forward = append(forward, b)
+			continue
+		}
+
+		cursor := sclient.inputFilterCursor
+		buf := append(sclient.inputFilterBuff, 0)
+		copy(buf[cursor+1:], buf[cursor:len(buf)-1])
+		buf[cursor] = b
+		sclient.inputFilterBuff = buf
+		sclient.inputFilterCursor = cursor + 1
+	}
+	return forward
+}
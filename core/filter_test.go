@@ -0,0 +1,135 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestWSConn起一个本地ws server/client对,供需要*websocket.Conn的测试使用
+func newTestWSConn(t *testing.T) (*websocket.Conn, func()) {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+	}))
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		srv.Close()
+		t.Fatalf("dial: %v", err)
+	}
+	return conn, func() {
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func TestFeedCmdInput_ControlBytesForwardedImmediately(t *testing.T) {
+	sclient := &SSHClient{Filter: &CommandFilter{Policy: PolicyDrop}}
+
+	// Ctrl-C, no \r/\n in the chunk: must be forwarded as-is, not held in inputFilterBuff
+	ctrlC := []byte{0x03}
+	if got := sclient.feedCmdInput(ctrlC, nil); string(got) != string(ctrlC) {
+		t.Fatalf("Ctrl-C not forwarded immediately: got %v", got)
+	}
+	if len(sclient.inputFilterBuff) != 0 {
+		t.Fatalf("Ctrl-C should not be buffered, buffer = %v", sclient.inputFilterBuff)
+	}
+
+	// up-arrow escape sequence ESC [ A
+	arrowUp := []byte{0x1b, '[', 'A'}
+	if got := sclient.feedCmdInput(arrowUp, nil); string(got) != string(arrowUp) {
+		t.Fatalf("arrow key not forwarded immediately: got %v", got)
+	}
+	if len(sclient.inputFilterBuff) != 0 {
+		t.Fatalf("arrow key should not be buffered, buffer = %v", sclient.inputFilterBuff)
+	}
+}
+
+func TestFeedCmdInput_BlocksDenyListedLine(t *testing.T) {
+	filter, err := NewCommandFilter([]string{regexp.QuoteMeta("rm -rf /")}, PolicyDrop, nil)
+	if err != nil {
+		t.Fatalf("NewCommandFilter: %v", err)
+	}
+	sclient := &SSHClient{Filter: filter}
+	ws, cleanup := newTestWSConn(t)
+	defer cleanup()
+
+	forwarded := sclient.feedCmdInput([]byte("rm -rf /\r"), ws)
+	if len(forwarded) != 0 {
+		t.Fatalf("expected blocked line to be dropped, got %q", forwarded)
+	}
+}
+
+func TestFeedCmdInput_ReconstructsLineAfterCursorEdits(t *testing.T) {
+	filter, err := NewCommandFilter([]string{regexp.QuoteMeta("rm -rf /")}, PolicyDrop, nil)
+	if err != nil {
+		t.Fatalf("NewCommandFilter: %v", err)
+	}
+	sclient := &SSHClient{Filter: filter}
+	ws, cleanup := newTestWSConn(t)
+	defer cleanup()
+
+	// type "rmf /", move left 3 times (past "f /"), insert " -r", then submit:
+	// the line actually submitted is "rm -rf /", which must be what gets checked against the deny-list.
+	sclient.feedCmdInput([]byte("rmf /"), ws)
+	leftArrow := []byte{0x1b, '[', 'D'}
+	sclient.feedCmdInput(leftArrow, ws)
+	sclient.feedCmdInput(leftArrow, ws)
+	sclient.feedCmdInput(leftArrow, ws)
+	sclient.feedCmdInput([]byte(" -r"), ws)
+	forwarded := sclient.feedCmdInput([]byte("\r"), ws)
+
+	if len(forwarded) != 0 {
+		t.Fatalf("expected the reconstructed line to be recognized as blocked and dropped, got %q", forwarded)
+	}
+}
+
+func TestFeedCmdInput_BackspaceRemovesFromReconstructedLine(t *testing.T) {
+	filter, err := NewCommandFilter([]string{regexp.QuoteMeta("rm -rf /")}, PolicyDrop, nil)
+	if err != nil {
+		t.Fatalf("NewCommandFilter: %v", err)
+	}
+	sclient := &SSHClient{Filter: filter}
+	ws, cleanup := newTestWSConn(t)
+	defer cleanup()
+
+	// type "rm -rf /x", backspace once to remove the trailing "x", then submit "rm -rf /"
+	sclient.feedCmdInput([]byte("rm -rf /x"), ws)
+	sclient.feedCmdInput([]byte{0x7f}, ws)
+	forwarded := sclient.feedCmdInput([]byte("\r"), ws)
+
+	if len(forwarded) != 0 {
+		t.Fatalf("expected the backspace-corrected line to be recognized as blocked and dropped, got %q", forwarded)
+	}
+}
+
+func TestFeedCmdInput_AllowsRegularLine(t *testing.T) {
+	filter, err := NewCommandFilter([]string{regexp.QuoteMeta("rm -rf /")}, PolicyDrop, nil)
+	if err != nil {
+		t.Fatalf("NewCommandFilter: %v", err)
+	}
+	sclient := &SSHClient{Filter: filter}
+	ws, cleanup := newTestWSConn(t)
+	defer cleanup()
+
+	forwarded := sclient.feedCmdInput([]byte("ls -la\r"), ws)
+	if string(forwarded) != "ls -la\r" {
+		t.Fatalf("expected allowed line to be forwarded unchanged, got %q", forwarded)
+	}
+}
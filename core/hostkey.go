@@ -0,0 +1,269 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// defaultKnownHostsPath是未显式配置HostKeyStore时的落盘位置
+const defaultKnownHostsPath = "known_hosts"
+
+// HostKeyMode决定HostKeyStore对未知/变更主机密钥的处理方式
+type HostKeyMode string
+
+const (
+	ModeStrict   HostKeyMode = "strict"   // 只信任known_hosts里已有的记录,未知或不符一律拒绝
+	ModeTOFU     HostKeyMode = "tofu"     // 首次连接时信任并记录(Trust On First Use),之后按strict校验
+	ModeInsecure HostKeyMode = "insecure" // 不做任何校验,等价于此前的ssh.InsecureIgnoreHostKey
+)
+
+// ErrHostKeyChanged在记录的指纹与服务端返回的不一致时返回,
+// 调用方可用SurfaceHostKeyWarning据此向浏览器推送一条可确认的警告,而不是直接断开连接;
+// Connect本身不处理它
+type ErrHostKeyChanged struct {
+	Hostname string
+	OldFP    string
+	NewFP    string
+}
+
+func (e *ErrHostKeyChanged) Error() string {
+	return fmt.Sprintf("host key for %s changed: %s -> %s", e.Hostname, e.OldFP, e.NewFP)
+}
+
+// HostKeyStore是host key校验与存取的接口,默认实现为file-backed的known_hosts文件
+type HostKeyStore interface {
+	// Callback返回一个可直接用作ssh.ClientConfig.HostKeyCallback的函数
+	Callback(mode HostKeyMode) ssh.HostKeyCallback
+	// List列出已记录的主机及其指纹
+	List() (map[string]string, error)
+	// Remove删除一条记录,使该主机下次连接重新走TOFU流程
+	Remove(hostname string) error
+}
+
+// FileHostKeyStore是基于golang.org/x/crypto/ssh/knownhosts的默认实现,
+// mu序列化对known_hosts文件的读写,避免并发连接同时TOFU-append或和List/Remove交叉写坏文件
+type FileHostKeyStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileHostKeyStore以path为known_hosts文件创建一个FileHostKeyStore,文件不存在时会被创建
+func NewFileHostKeyStore(path string) (*FileHostKeyStore, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+	}
+	return &FileHostKeyStore{path: path}, nil
+}
+
+// Callback按mode返回host key校验函数:
+// insecure从不校验;strict只接受known_hosts里已有的记录;tofu在未知主机上首次信任并记录,
+// 记录存在但指纹不符时两种模式都返回*ErrHostKeyChanged
+func (s *FileHostKeyStore) Callback(mode HostKeyMode) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if mode == ModeInsecure {
+			return nil
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		cb, err := knownhosts.New(s.path)
+		if err != nil {
+			return err
+		}
+		err = cb(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		keyErr, ok := err.(*knownhosts.KeyError)
+		if !ok {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			// 已有记录但指纹不符,无论strict还是tofu都不能静默信任,否则MITM防护形同虚设
+			return &ErrHostKeyChanged{
+				Hostname: hostname,
+				OldFP:    ssh.FingerprintSHA256(keyErr.Want[0].Key),
+				NewFP:    ssh.FingerprintSHA256(key),
+			}
+		}
+		// Want为空表示主机未知
+		if mode == ModeStrict {
+			return keyErr
+		}
+		return s.append(hostname, key)
+	}
+}
+
+// append假定调用方已持有s.mu
+func (s *FileHostKeyStore) append(hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	_, err = fmt.Fprintln(f, line)
+	return err
+}
+
+// List解析known_hosts文件,返回hostname到指纹的映射
+func (s *FileHostKeyStore) List() (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fingerprints := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		_, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err != nil {
+			continue
+		}
+		for _, host := range hosts {
+			fingerprints[host] = ssh.FingerprintSHA256(key)
+		}
+	}
+	return fingerprints, scanner.Err()
+}
+
+// Remove从known_hosts里摘除hostname对应的所有行,hostname未匹配到任何记录时返回os.ErrNotExist
+func (s *FileHostKeyStore) Remove(hostname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	var kept []string
+	removed := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			kept = append(kept, line)
+			continue
+		}
+		_, hosts, _, _, _, err := ssh.ParseKnownHosts([]byte(trimmed))
+		if err == nil {
+			matched := false
+			for _, h := range hosts {
+				if h == hostname || h == knownhosts.Normalize(hostname) {
+					matched = true
+					break
+				}
+			}
+			if matched {
+				removed = true
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !removed {
+		return os.ErrNotExist
+	}
+	return os.WriteFile(s.path, []byte(strings.Join(kept, "\n")+"\n"), 0o600)
+}
+
+// hostKeyCallback按sclient上配置的HostKeyStore/HostKeyMode选出校验函数。
+// 未显式注入HostKeyStore时,懒加载一个指向defaultKnownHostsPath的FileHostKeyStore并以tofu模式校验,
+// 而不是像此前那样静默回退到InsecureIgnoreHostKey——这样即使上层没有接入HostKeyStore,
+// 新建立的连接也会默认享有TOFU保护。
+func (sclient *SSHClient) hostKeyCallback() ssh.HostKeyCallback {
+	if sclient.HostKeyStore == nil {
+		store, err := NewFileHostKeyStore(defaultKnownHostsPath)
+		if err != nil {
+			log.Println(err)
+			return ssh.InsecureIgnoreHostKey()
+		}
+		sclient.HostKeyStore = store
+	}
+	mode := sclient.HostKeyMode
+	if mode == "" {
+		mode = ModeTOFU
+	}
+	return sclient.HostKeyStore.Callback(mode)
+}
+
+// SurfaceHostKeyWarning在err是*ErrHostKeyChanged时,把它转成一条{"type":"warn",...}帧推给ws,
+// 让调用GenerateClient失败的那一层可以提示用户手动确认,而不是直接断开连接。
+// 返回true表示err已被处理为警告帧(调用方不应再把它当作致命错误展示)
+func SurfaceHostKeyWarning(ws *websocket.Conn, err error) bool {
+	changed, ok := err.(*ErrHostKeyChanged)
+	if !ok {
+		return false
+	}
+	msg, _ := json.Marshal(WSMsg{Type: MsgTypeWarn, Data: changed.Error()})
+	ws.WriteMessage(websocket.TextMessage, msg)
+	return true
+}
+
+// HostKeyListHandler处理 GET /hostkeys,列出已记录的主机指纹
+func HostKeyListHandler(store HostKeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fingerprints, err := store.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fingerprints)
+	}
+}
+
+// HostKeyDeleteHandler处理 DELETE /hostkeys/{hostname},移除一条记录强制其重新TOFU
+func HostKeyDeleteHandler(store HostKeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hostname, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/hostkeys/"))
+		if err != nil || hostname == "" {
+			http.Error(w, "missing hostname", http.StatusBadRequest)
+			return
+		}
+		if err := store.Remove(hostname); err != nil {
+			if os.IsNotExist(err) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
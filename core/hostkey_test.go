@@ -0,0 +1,100 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func genTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("NewPublicKey: %v", err)
+	}
+	return sshPub
+}
+
+func TestFileHostKeyStore_TOFUTrustsThenEnforces(t *testing.T) {
+	store, err := NewFileHostKeyStore(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatalf("NewFileHostKeyStore: %v", err)
+	}
+	cb := store.Callback(ModeTOFU)
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+	key := genTestHostKey(t)
+
+	if err := cb("example.com:22", addr, key); err != nil {
+		t.Fatalf("first connection should be trusted on first use, got: %v", err)
+	}
+	if err := cb("example.com:22", addr, key); err != nil {
+		t.Fatalf("second connection with the same key should still be trusted, got: %v", err)
+	}
+
+	other := genTestHostKey(t)
+	err = cb("example.com:22", addr, other)
+	if _, ok := err.(*ErrHostKeyChanged); !ok {
+		t.Fatalf("expected *ErrHostKeyChanged for a changed key, got: %v", err)
+	}
+}
+
+func TestFileHostKeyStore_StrictRejectsUnknownHost(t *testing.T) {
+	store, err := NewFileHostKeyStore(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatalf("NewFileHostKeyStore: %v", err)
+	}
+	cb := store.Callback(ModeStrict)
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+
+	if err := cb("example.com:22", addr, genTestHostKey(t)); err == nil {
+		t.Fatal("strict mode should reject a host with no existing record")
+	}
+}
+
+func TestFileHostKeyStore_InsecureSkipsVerification(t *testing.T) {
+	store, err := NewFileHostKeyStore(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatalf("NewFileHostKeyStore: %v", err)
+	}
+	cb := store.Callback(ModeInsecure)
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+
+	if err := cb("example.com:22", addr, genTestHostKey(t)); err != nil {
+		t.Fatalf("insecure mode must never fail verification, got: %v", err)
+	}
+}
+
+func TestFileHostKeyStore_ListAndRemove(t *testing.T) {
+	store, err := NewFileHostKeyStore(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatalf("NewFileHostKeyStore: %v", err)
+	}
+	cb := store.Callback(ModeTOFU)
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+	if err := cb("example.com:22", addr, genTestHostKey(t)); err != nil {
+		t.Fatalf("tofu connect: %v", err)
+	}
+
+	fingerprints, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if _, ok := fingerprints["example.com"]; !ok {
+		t.Fatalf("expected example.com in %v", fingerprints)
+	}
+
+	if err := store.Remove("example.com:22"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := store.Remove("example.com:22"); err == nil {
+		t.Fatal("removing an already-removed host should report not-found")
+	}
+}
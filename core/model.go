@@ -0,0 +1,69 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHClient 保存一次ssh连接所需的全部信息
+type SSHClient struct {
+	IPAddress string `json:"ip_address"`
+	Port      int    `json:"port"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+
+	// PrivateKey/PrivateKeyPath/Passphrase/UseAgent 为密码之外的可选认证方式,
+	// GenerateClient/ExecRemoteCommand 按 私钥 > agent > 密码 的顺序组装 ssh.AuthMethod
+	PrivateKey     []byte `json:"private_key,omitempty"`
+	PrivateKeyPath string `json:"private_key_path,omitempty"`
+	Passphrase     string `json:"passphrase,omitempty"`
+	UseAgent       bool   `json:"use_agent,omitempty"`
+
+	Client      *ssh.Client
+	Session     *ssh.Session
+	StdinPipe   io.WriteCloser
+	ComboOutput *wsBufferWriter
+
+	// Recorder录制本次会话的输入输出,为nil时不录制,见NewSessionRecorder
+	Recorder *SessionRecorder
+
+	// Filter为nil时不做命令审计,非nil时按其DenyList/Policy过滤写往StdinPipe的每一行命令。
+	// inputFilterBuff/inputFilterCursor 是尚未提交(没有遇到\r/\n)的当前行内容及光标位置,
+	// 退格/Ctrl-U/左右方向键都会修改它们,feedCmdInput据此重建出真正会被提交的那一行,
+	// 而不是假设用户只会不断追加输入,见filter.go
+	Filter            *CommandFilter
+	IsFlagged         bool
+	inputFilterBuff   []byte
+	inputFilterCursor int
+
+	// HostKeyStore为nil时懒加载一个指向defaultKnownHostsPath的FileHostKeyStore,
+	// 按HostKeyMode(为空时取tofu)校验host key,见hostkey.go的hostKeyCallback
+	HostKeyStore HostKeyStore
+	HostKeyMode  HostKeyMode
+
+	// sftpClient懒加载并在整个ws会话期间复用,避免每个sftp-*帧都重新握手一次sftp子系统;
+	// uploads以remotePath为key保存跨多个upload-chunk帧仍然打开的文件句柄,见sftpclient.go
+	sftpClient *SFTPClient
+	uploads    map[string]*sftp.File
+}
+
+// NewSSHClient 创建一个空的SSHClient
+func NewSSHClient() SSHClient {
+	return SSHClient{}
+}
+
+// wsBufferWriter 将ssh会话的输出先缓存起来，再定时刷给websocket
+type wsBufferWriter struct {
+	buffer bytes.Buffer
+	mu     sync.Mutex
+}
+
+func (w *wsBufferWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buffer.Write(p)
+}
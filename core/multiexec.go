@@ -0,0 +1,141 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ExecResult是MultiExec里单台主机的执行结果
+type ExecResult struct {
+	Host     string        `json:"host"`
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	ExitCode int           `json:"exit_code"`
+	Err      error         `json:"-"`
+	ErrMsg   string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// MultiExec 用一个大小为concurrency的worker池在多台主机上并发执行同一条命令,
+// 结果按完成顺序写到返回的channel。ctx取消时还未开始的主机不再执行,
+// 已经在执行的也会被ExecRemoteCommand中断(关闭session)而不是跑到自然结束
+func MultiExec(ctx context.Context, hosts []SSHClient, command string, concurrency int) <-chan ExecResult {
+	hosts = withSharedHostKeyStore(hosts)
+	results := make(chan ExecResult, len(hosts))
+	sem := make(chan struct{}, concurrency)
+
+	go func() {
+		defer close(results)
+		var wg sync.WaitGroup
+		for i := range hosts {
+			host := hosts[i]
+			select {
+			case <-ctx.Done():
+				results <- ExecResult{Host: host.IPAddress, ExitCode: -1, Err: ctx.Err(), ErrMsg: ctx.Err().Error()}
+				continue
+			case sem <- struct{}{}:
+			}
+			wg.Add(1)
+			go func(h SSHClient) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				start := time.Now()
+				stdout, stderr, exitCode, err := h.ExecRemoteCommand(ctx, command)
+				result := ExecResult{
+					Host:     h.IPAddress,
+					Stdout:   stdout,
+					Stderr:   stderr,
+					ExitCode: exitCode,
+					Err:      err,
+					Duration: time.Since(start),
+				}
+				if err != nil {
+					result.ErrMsg = err.Error()
+				}
+				results <- result
+			}(host)
+		}
+		wg.Wait()
+	}()
+
+	return results
+}
+
+// withSharedHostKeyStore让一批未显式配置HostKeyStore的主机共享同一个*FileHostKeyStore实例,
+// 而不是各自在hostKeyCallback里懒加载一个私有实例:它们虽然指向同一个known_hosts路径,
+// 但各自的mu互不相干起不到互斥作用,并发首次连接同一台未知主机时可能同时触发TOFU append,
+// 把known_hosts文件追加写坏。调用方已显式设置的HostKeyStore不受影响
+func withSharedHostKeyStore(hosts []SSHClient) []SSHClient {
+	needsShared := false
+	for i := range hosts {
+		if hosts[i].HostKeyStore == nil {
+			needsShared = true
+			break
+		}
+	}
+	if !needsShared {
+		return hosts
+	}
+	shared, err := NewFileHostKeyStore(defaultKnownHostsPath)
+	if err != nil {
+		log.Println(err)
+		return hosts // 构造失败就维持原状,交给各自的hostKeyCallback兜底处理
+	}
+	for i := range hosts {
+		if hosts[i].HostKeyStore == nil {
+			hosts[i].HostKeyStore = shared
+		}
+	}
+	return hosts
+}
+
+// MultiExecHandler 处理批量执行请求: body为若干base64编码的SSHClient blob和待执行的命令,
+// 以NDJSON(每行一个ExecResult)流式返回,供前端实现简单的多主机执行器
+func MultiExecHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Hosts       []string `json:"hosts"` // 每项为DecodedMsgToSSHClient接受的base64 blob
+		Command     string   `json:"command"`
+		Concurrency int      `json:"concurrency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Concurrency <= 0 {
+		req.Concurrency = 1
+	}
+
+	clients := make([]SSHClient, 0, len(req.Hosts))
+	for _, blob := range req.Hosts {
+		client, err := DecodedMsgToSSHClient(blob)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		clients = append(clients, client)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+
+	results := MultiExec(r.Context(), clients, req.Command, req.Concurrency)
+	for result := range results {
+		line, err := json.Marshal(result)
+		if err != nil {
+			continue
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+		writer.Flush()
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdirTemp切到一个临时目录下跑测试,避免withSharedHostKeyStore懒加载的默认
+// known_hosts文件写到repo目录里
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	orig, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(orig) })
+}
+
+func TestWithSharedHostKeyStore_AssignsSameInstance(t *testing.T) {
+	chdirTemp(t)
+
+	hosts := []SSHClient{{IPAddress: "10.0.0.1"}, {IPAddress: "10.0.0.2"}, {IPAddress: "10.0.0.3"}}
+	hosts = withSharedHostKeyStore(hosts)
+
+	first := hosts[0].HostKeyStore
+	if first == nil {
+		t.Fatal("expected HostKeyStore to be assigned")
+	}
+	for i, h := range hosts {
+		if h.HostKeyStore != first {
+			t.Fatalf("host %d got a different HostKeyStore instance, want shared", i)
+		}
+	}
+}
+
+func TestWithSharedHostKeyStore_LeavesExplicitStoreAlone(t *testing.T) {
+	chdirTemp(t)
+
+	custom, err := NewFileHostKeyStore(filepath.Join(t.TempDir(), "custom_known_hosts"))
+	if err != nil {
+		t.Fatalf("NewFileHostKeyStore: %v", err)
+	}
+	hosts := []SSHClient{{IPAddress: "10.0.0.1", HostKeyStore: custom}, {IPAddress: "10.0.0.2"}}
+	hosts = withSharedHostKeyStore(hosts)
+
+	if hosts[0].HostKeyStore != custom {
+		t.Fatal("explicit HostKeyStore must not be overwritten")
+	}
+	if hosts[1].HostKeyStore == nil || hosts[1].HostKeyStore == custom {
+		t.Fatal("host without an explicit store should get its own shared default, not the custom one")
+	}
+}
@@ -0,0 +1,21 @@
+package core
+
+// WSMsg是浏览器与服务端之间统一的websocket消息信封,替代此前基于字符串匹配的
+// 临时协议(strings.Contains("resize")之类极易和用户输入混淆)。
+// Type决定如何解释消息: cmd/resize/ping/close用于控制交互式终端,
+// sftp-*路由给SFTPClient,服务端主动推送的stdout/warn等也复用同一个信封。
+type WSMsg struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"` // cmd的Data为base64编码,保证方向键/Ctrl-C等二进制按键不被破坏
+	Cols int    `json:"cols,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+}
+
+const (
+	MsgTypeCmd    = "cmd"
+	MsgTypeResize = "resize"
+	MsgTypePing   = "ping"
+	MsgTypeClose  = "close"
+	MsgTypeStdout = "stdout"
+	MsgTypeWarn   = "warn"
+)
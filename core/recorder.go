@@ -0,0 +1,100 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// asciicastHeader 是asciicast v2格式的首行,描述终端尺寸和录制环境
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env"`
+}
+
+// SessionRecorder 把一次交互式会话的输入输出录制为asciicast v2格式
+// 写给io.Writer,供回放接口读取
+type SessionRecorder struct {
+	w         io.Writer
+	mu        sync.Mutex
+	start     time.Time
+	recordIn  bool
+	headerSet bool
+}
+
+// NewSessionRecorder 创建一个录制器,rows/cols为初始终端尺寸,
+// recordInput决定是否也记录用户按键(input事件),startUnix为会话起始的unix时间戳
+func NewSessionRecorder(w io.Writer, rows, cols int, recordInput bool, startUnix int64) (*SessionRecorder, error) {
+	r := &SessionRecorder{w: w, start: time.Now(), recordIn: recordInput}
+	header := asciicastHeader{
+		Version:   2,
+		Width:     cols,
+		Height:    rows,
+		Timestamp: startUnix,
+		Env:       map[string]string{"SHELL": "/bin/bash", "TERM": "xterm"},
+	}
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(w, "%s\n", line); err != nil {
+		return nil, err
+	}
+	r.headerSet = true
+	return r, nil
+}
+
+func (r *SessionRecorder) writeEvent(kind string, data string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	elapsed := time.Since(r.start).Seconds()
+	event := []interface{}{elapsed, kind, data}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(r.w, "%s\n", line)
+	return err
+}
+
+// WriteOutput 记录一段远程主机输出
+func (r *SessionRecorder) WriteOutput(p []byte) error {
+	return r.writeEvent("o", string(p))
+}
+
+// WriteInput 记录一段用户键入,仅在recordIn为true时生效
+func (r *SessionRecorder) WriteInput(p []byte) error {
+	if !r.recordIn {
+		return nil
+	}
+	return r.writeEvent("i", string(p))
+}
+
+// Resize 记录一次窗口尺寸变化。"r"不是asciicast v2标准事件类型(标准只定义了"o"/"i"),
+// 但标准播放器对无法识别的事件类型码是直接跳过而不是报错中止,所以不影响回放,
+// 代价是旧版本/非标准播放器可能看不到这次resize——数据字段是"rowsxcols",和文档格式保持一致
+func (r *SessionRecorder) Resize(rows, cols int) error {
+	return r.writeEvent("r", fmt.Sprintf("%dx%d", rows, cols))
+}
+
+// recorderTee 包装一个io.Writer,每次Write都会先转发给底层writer,
+// 再把同样的数据喂给SessionRecorder.WriteOutput,用作ComboOutput的tee
+type recorderTee struct {
+	w        io.Writer
+	recorder *SessionRecorder
+}
+
+func (t *recorderTee) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if err == nil {
+		if rerr := t.recorder.WriteOutput(p); rerr != nil {
+			return n, rerr
+		}
+	}
+	return n, err
+}
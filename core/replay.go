@@ -0,0 +1,85 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RecordStore 管理asciicast录像文件的落盘位置,key为session id
+type RecordStore struct {
+	dir string
+}
+
+// NewRecordStore 创建一个以dir为根目录的录像存储
+func NewRecordStore(dir string) *RecordStore {
+	return &RecordStore{dir: dir}
+}
+
+func (s *RecordStore) path(sessionID string) string {
+	return filepath.Join(s.dir, sessionID+".cast")
+}
+
+// Create 为sessionID打开一个用于写入的录像文件,调用方负责在会话结束时Close
+func (s *RecordStore) Create(sessionID string) (*os.File, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(s.path(sessionID))
+}
+
+// sessionIDFromPath 从形如 /sessions/{id}/replay 或 /sessions/{id}.cast 的路径中取出id
+func sessionIDFromPath(urlPath string) string {
+	trimmed := strings.TrimSuffix(urlPath, "/replay")
+	trimmed = strings.TrimSuffix(trimmed, ".cast")
+	parts := strings.Split(strings.Trim(trimmed, "/"), "/")
+	return parts[len(parts)-1]
+}
+
+// ReplayHandler 处理 GET /sessions/{id}/replay,把asciicast内容以text/plain流回,
+// 供前端配合asciinema-player播放
+func (s *RecordStore) ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	id := sessionIDFromPath(r.URL.Path)
+	f, err := os.Open(s.path(id))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write(readAll(f)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// DownloadHandler 处理 GET /sessions/{id}.cast,把原始asciicast文件作为附件下载
+func (s *RecordStore) DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	id := sessionIDFromPath(r.URL.Path)
+	f, err := os.Open(s.path(id))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.cast", id))
+	if _, err := w.Write(readAll(f)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// readAll读出f的全部内容。单次f.Read在.cast文件较大、跨越多个底层read syscall时
+// 可能只读到一部分就返回,之前的实现会把这截断的结果当成完整录像回给前端,
+// 用io.ReadAll循环读到真正的EOF为止
+func readAll(f *os.File) []byte {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return data
+}
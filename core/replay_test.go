@@ -0,0 +1,162 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSessionRecorder_HeaderAndEventShapes(t *testing.T) {
+	var buf strings.Builder
+	if _, err := NewSessionRecorder(&buf, 24, 80, true, 1700000000); err != nil {
+		t.Fatalf("NewSessionRecorder: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected exactly one header line after construction, got %d: %v", len(lines), lines)
+	}
+	var header asciicastHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatalf("header did not unmarshal: %v", err)
+	}
+	if header.Version != 2 || header.Width != 80 || header.Height != 24 || header.Timestamp != 1700000000 {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+	if header.Env["SHELL"] == "" || header.Env["TERM"] == "" {
+		t.Fatalf("expected SHELL/TERM in header env, got %+v", header.Env)
+	}
+}
+
+func TestSessionRecorder_OutputInputResizeEvents(t *testing.T) {
+	var buf strings.Builder
+	r, err := NewSessionRecorder(&buf, 24, 80, true, 1700000000)
+	if err != nil {
+		t.Fatalf("NewSessionRecorder: %v", err)
+	}
+
+	if err := r.WriteOutput([]byte("hello")); err != nil {
+		t.Fatalf("WriteOutput: %v", err)
+	}
+	if err := r.WriteInput([]byte("ls\r")); err != nil {
+		t.Fatalf("WriteInput: %v", err)
+	}
+	if err := r.Resize(40, 120); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 4 { // header + o + i + r
+		t.Fatalf("expected 4 lines (header + 3 events), got %d: %v", len(lines), lines)
+	}
+
+	var outEvent, inEvent, resizeEvent [3]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &outEvent); err != nil {
+		t.Fatalf("output event did not unmarshal: %v", err)
+	}
+	if outEvent[1] != "o" || outEvent[2] != "hello" {
+		t.Fatalf("unexpected output event: %v", outEvent)
+	}
+
+	if err := json.Unmarshal([]byte(lines[2]), &inEvent); err != nil {
+		t.Fatalf("input event did not unmarshal: %v", err)
+	}
+	if inEvent[1] != "i" || inEvent[2] != "ls\r" {
+		t.Fatalf("unexpected input event: %v", inEvent)
+	}
+
+	if err := json.Unmarshal([]byte(lines[3]), &resizeEvent); err != nil {
+		t.Fatalf("resize event did not unmarshal: %v", err)
+	}
+	if resizeEvent[1] != "r" || resizeEvent[2] != "40x120" {
+		t.Fatalf("unexpected resize event %v, want data \"rowsxcols\" = \"40x120\"", resizeEvent)
+	}
+}
+
+func TestSessionRecorder_WriteInputSkippedWhenNotRecording(t *testing.T) {
+	var buf strings.Builder
+	r, err := NewSessionRecorder(&buf, 24, 80, false, 1700000000)
+	if err != nil {
+		t.Fatalf("NewSessionRecorder: %v", err)
+	}
+	if err := r.WriteInput([]byte("ls\r")); err != nil {
+		t.Fatalf("WriteInput: %v", err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected input events to be skipped when recordIn is false, got lines: %v", lines)
+	}
+}
+
+func TestRecorderTee_ForwardsAndRecords(t *testing.T) {
+	var recorded strings.Builder
+	r, err := NewSessionRecorder(&recorded, 24, 80, false, 1700000000)
+	if err != nil {
+		t.Fatalf("NewSessionRecorder: %v", err)
+	}
+	var downstream strings.Builder
+	tee := &recorderTee{w: &downstream, recorder: r}
+
+	if _, err := tee.Write([]byte("combo output")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if downstream.String() != "combo output" {
+		t.Fatalf("downstream writer got %q, want %q", downstream.String(), "combo output")
+	}
+	if !strings.Contains(recorded.String(), `"combo output"`) {
+		t.Fatalf("expected recorder to capture the same bytes, got %q", recorded.String())
+	}
+}
+
+func TestRecordStore_ReplayAndDownloadHandlers(t *testing.T) {
+	store := NewRecordStore(t.TempDir())
+	f, err := store.Create("sess-1")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := f.WriteString(`{"version":2,"width":80,"height":24,"timestamp":1700000000,"env":{}}` + "\n"); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	f.Close()
+
+	replayReq := httptest.NewRequest(http.MethodGet, "/sessions/sess-1/replay", nil)
+	replayRec := httptest.NewRecorder()
+	store.ReplayHandler(replayRec, replayReq)
+	if replayRec.Code != http.StatusOK {
+		t.Fatalf("ReplayHandler status = %d, want 200", replayRec.Code)
+	}
+	if !strings.Contains(replayRec.Body.String(), `"version":2`) {
+		t.Fatalf("ReplayHandler body missing header, got %q", replayRec.Body.String())
+	}
+
+	downloadReq := httptest.NewRequest(http.MethodGet, "/sessions/sess-1.cast", nil)
+	downloadRec := httptest.NewRecorder()
+	store.DownloadHandler(downloadRec, downloadReq)
+	if downloadRec.Code != http.StatusOK {
+		t.Fatalf("DownloadHandler status = %d, want 200", downloadRec.Code)
+	}
+	if got := downloadRec.Header().Get("Content-Disposition"); !strings.Contains(got, "sess-1.cast") {
+		t.Fatalf("Content-Disposition = %q, want it to reference sess-1.cast", got)
+	}
+
+	missingReq := httptest.NewRequest(http.MethodGet, "/sessions/does-not-exist/replay", nil)
+	missingRec := httptest.NewRecorder()
+	store.ReplayHandler(missingRec, missingReq)
+	if missingRec.Code != http.StatusNotFound {
+		t.Fatalf("ReplayHandler for missing session status = %d, want 404", missingRec.Code)
+	}
+}
+
+func TestSessionIDFromPath(t *testing.T) {
+	cases := map[string]string{
+		"/sessions/abc123/replay": "abc123",
+		"/sessions/abc123.cast":   "abc123",
+	}
+	for path, want := range cases {
+		if got := sessionIDFromPath(path); got != want {
+			t.Errorf("sessionIDFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
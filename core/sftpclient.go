@@ -0,0 +1,236 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/sftp"
+)
+
+// FileInfo 描述远程文件系统上的一项,供前端文件列表展示使用
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	IsDir   bool      `json:"is_dir"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// SFTPClient 基于已建立的ssh连接提供文件上传/下载能力
+type SFTPClient struct {
+	sclient *SSHClient
+	client  *sftp.Client
+}
+
+// NewSFTPClient 复用sclient.Client上已经建立的ssh连接创建sftp客户端
+func NewSFTPClient(sclient *SSHClient) (*SFTPClient, error) {
+	client, err := sftp.NewClient(sclient.Client)
+	if err != nil {
+		return nil, err
+	}
+	return &SFTPClient{sclient: sclient, client: client}, nil
+}
+
+// Close 关闭底层sftp连接
+func (s *SFTPClient) Close() error {
+	return s.client.Close()
+}
+
+// Upload 将localReader中的内容一次性写入远程的remotePath,返回写入的字节数。
+// 用于调用方已经拿到完整内容的场景;分片上传见CreateForWrite。
+func (s *SFTPClient) Upload(localReader io.Reader, remotePath string) (int64, error) {
+	remoteFile, err := s.client.Create(remotePath)
+	if err != nil {
+		return 0, err
+	}
+	defer remoteFile.Close()
+	return remoteFile.ReadFrom(localReader)
+}
+
+// CreateForWrite以O_TRUNC创建(或覆盖)remotePath并返回文件句柄,
+// 调用方应在每个upload-chunk帧到达时往这同一个句柄上写入,并在upload-end时Close——
+// 每个chunk都重新Create会把之前写入的内容截断掉
+func (s *SFTPClient) CreateForWrite(remotePath string) (*sftp.File, error) {
+	return s.client.Create(remotePath)
+}
+
+// Download 读取远程remotePath的内容并写入w,返回读取的字节数
+func (s *SFTPClient) Download(remotePath string, w io.Writer) (int64, error) {
+	remoteFile, err := s.client.Open(remotePath)
+	if err != nil {
+		return 0, err
+	}
+	defer remoteFile.Close()
+	return remoteFile.WriteTo(w)
+}
+
+// List 列出remotePath目录下的文件
+func (s *SFTPClient) List(remotePath string) ([]FileInfo, error) {
+	entries, err := s.client.ReadDir(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		infos = append(infos, FileInfo{
+			Name:    entry.Name(),
+			Size:    entry.Size(),
+			Mode:    entry.Mode().String(),
+			IsDir:   entry.IsDir(),
+			ModTime: entry.ModTime(),
+		})
+	}
+	return infos, nil
+}
+
+// Remove 删除远程文件
+func (s *SFTPClient) Remove(remotePath string) error {
+	return s.client.Remove(remotePath)
+}
+
+// Rename 重命名/移动远程文件
+func (s *SFTPClient) Rename(oldPath, newPath string) error {
+	return s.client.Rename(oldPath, newPath)
+}
+
+// Mkdir 创建远程目录
+func (s *SFTPClient) Mkdir(remotePath string) error {
+	return s.client.MkdirAll(remotePath)
+}
+
+// Sha256Sum 在远程主机上执行sha256sum校验文件完整性
+func (s *SFTPClient) Sha256Sum(remotePath string) (string, error) {
+	session, err := s.sclient.Client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	out, err := session.CombinedOutput(fmt.Sprintf("sha256sum %s", remotePath))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unexpected sha256sum output: %q", out)
+	}
+	return fields[0], nil
+}
+
+// sftpFrame 是WSMsg里Type以"sftp-"开头的消息载荷,Type去掉前缀后即为具体操作
+// (ls/upload-start/upload-chunk/upload-end/download/mkdir/rename/remove)
+type sftpFrame struct {
+	Type    string `json:"type"`
+	Path    string `json:"path"`
+	NewPath string `json:"new_path,omitempty"`
+	Data    string `json:"data,omitempty"` // base64编码的文件内容分片
+}
+
+// sftpSession懒加载并缓存一个*SFTPClient,在整个ws会话期间复用,
+// 这样多个sftp-*帧(尤其是同一次拖拽上传的多个upload-chunk)不需要每帧都重新握手sftp子系统
+func (sclient *SSHClient) sftpSession() (*SFTPClient, error) {
+	if sclient.sftpClient != nil {
+		return sclient.sftpClient, nil
+	}
+	client, err := NewSFTPClient(sclient)
+	if err != nil {
+		return nil, err
+	}
+	sclient.sftpClient = client
+	return client, nil
+}
+
+// CloseSFTP关闭缓存的sftp子系统连接,以及任何还没有收到upload-end就中断的上传句柄,
+// 应当在ws会话结束时调用
+func (sclient *SSHClient) CloseSFTP() {
+	for path, f := range sclient.uploads {
+		f.Close()
+		delete(sclient.uploads, path)
+	}
+	if sclient.sftpClient != nil {
+		sclient.sftpClient.Close()
+		sclient.sftpClient = nil
+	}
+}
+
+// handleSFTPFrame 解析一帧sftp消息并路由到SFTPClient上对应的操作,
+// 让web前端可以在同一个ws会话里拖拽上传/下载文件而无需再开一条tcp连接。
+// 多分片上传靠uploads里缓存的文件句柄串联: upload-start用O_TRUNC打开一次,
+// 后续的upload-chunk只在该句柄上追加写,upload-end才真正Close。
+func (sclient *SSHClient) handleSFTPFrame(p []byte, ws *websocket.Conn) error {
+	var frame sftpFrame
+	if err := json.Unmarshal(p, &frame); err != nil {
+		return err
+	}
+	sftpClient, err := sclient.sftpSession()
+	if err != nil {
+		return err
+	}
+
+	switch strings.TrimPrefix(frame.Type, "sftp-") {
+	case "ls":
+		infos, err := sftpClient.List(frame.Path)
+		if err != nil {
+			return err
+		}
+		resp, _ := json.Marshal(struct {
+			Type  string     `json:"type"`
+			Files []FileInfo `json:"files"`
+		}{Type: "sftp-ls", Files: infos})
+		return ws.WriteMessage(websocket.TextMessage, resp)
+	case "upload-start":
+		f, err := sftpClient.CreateForWrite(frame.Path)
+		if err != nil {
+			return err
+		}
+		if sclient.uploads == nil {
+			sclient.uploads = make(map[string]*sftp.File)
+		}
+		if old, ok := sclient.uploads[frame.Path]; ok {
+			old.Close()
+		}
+		sclient.uploads[frame.Path] = f
+		return nil
+	case "upload-chunk":
+		f, ok := sclient.uploads[frame.Path]
+		if !ok {
+			return fmt.Errorf("upload not started for %s", frame.Path)
+		}
+		chunk, err := base64.StdEncoding.DecodeString(frame.Data)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(chunk)
+		return err
+	case "upload-end":
+		f, ok := sclient.uploads[frame.Path]
+		if !ok {
+			return fmt.Errorf("upload not started for %s", frame.Path)
+		}
+		delete(sclient.uploads, frame.Path)
+		return f.Close()
+	case "download":
+		var buf bytes.Buffer
+		if _, err := sftpClient.Download(frame.Path, &buf); err != nil {
+			return err
+		}
+		resp, _ := json.Marshal(struct {
+			Type string `json:"type"`
+			Data string `json:"data"`
+		}{Type: "sftp-download", Data: base64.StdEncoding.EncodeToString(buf.Bytes())})
+		return ws.WriteMessage(websocket.TextMessage, resp)
+	case "mkdir":
+		return sftpClient.Mkdir(frame.Path)
+	case "rename":
+		return sftpClient.Rename(frame.Path, frame.NewPath)
+	case "remove":
+		return sftpClient.Remove(frame.Path)
+	default:
+		return fmt.Errorf("unknown sftp message type: %s", frame.Type)
+	}
+}
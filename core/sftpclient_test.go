@@ -0,0 +1,298 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestSSHServer起一个只服务sftp子系统和"sha256sum <path>"exec请求的内存ssh server,
+// 文件落在rootDir下,供SFTPClient/handleSFTPFrame的测试使用一个真正的ssh.Client而不必打桩。
+func newTestSSHServer(t *testing.T, rootDir string) *ssh.Client {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	signer, err := ssh.NewSignerFromSigner(priv)
+	if err != nil {
+		t.Fatalf("NewSignerFromSigner: %v", err)
+	}
+
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	// net.Pipe的同步、无缓冲读写会在ssh握手的某些阶段互相等待对方先读/先写而死锁,
+	// 用本地回环tcp端口代替,和真实ssh.Dial走的是同一条路径
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		sconn, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		for newChannel := range chans {
+			if newChannel.ChannelType() != "session" {
+				newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+				continue
+			}
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go serveTestSession(channel, requests, rootDir)
+		}
+		sconn.Wait()
+	}()
+
+	client, err := ssh.Dial("tcp", ln.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		Auth:            []ssh.AuthMethod{ssh.Password("x")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// serveTestSession处理单个session channel上的请求:"subsystem sftp"交给一个真正的
+// sftp.Server(落盘到rootDir),"exec"则只识别Sha256Sum用到的"sha256sum <path>"命令
+func serveTestSession(channel ssh.Channel, requests <-chan *ssh.Request, rootDir string) {
+	defer channel.Close()
+	for req := range requests {
+		switch req.Type {
+		case "subsystem":
+			name := parseSSHString(req.Payload)
+			if req.WantReply {
+				req.Reply(name == "sftp", nil)
+			}
+			if name != "sftp" {
+				continue
+			}
+			srv, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(rootDir))
+			if err != nil {
+				return
+			}
+			srv.Serve()
+			return
+		case "exec":
+			cmd := parseSSHString(req.Payload)
+			if req.WantReply {
+				req.Reply(true, nil)
+			}
+			runFakeExec(channel, rootDir, cmd)
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			return
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// parseSSHString解出ssh请求payload里单个uint32长度前缀的字符串(subsystem/exec请求都是这个格式)
+func parseSSHString(payload []byte) string {
+	if len(payload) < 4 {
+		return ""
+	}
+	n := binary.BigEndian.Uint32(payload[:4])
+	if int(n) > len(payload)-4 {
+		return ""
+	}
+	return string(payload[4 : 4+n])
+}
+
+// runFakeExec只实现Sha256Sum依赖的"sha256sum <path>"这一种命令,本地算出哈希后
+// 按sha256sum命令行工具的输出格式写回,不依赖系统上真的装了这个工具
+func runFakeExec(channel ssh.Channel, rootDir, cmd string) {
+	const prefix = "sha256sum "
+	if !strings.HasPrefix(cmd, prefix) {
+		return
+	}
+	remotePath := strings.TrimSpace(strings.TrimPrefix(cmd, prefix))
+	data, err := os.ReadFile(filepath.Join(rootDir, strings.TrimPrefix(remotePath, "/")))
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	fmt.Fprintf(channel, "%x  %s\n", sum, remotePath)
+}
+
+func TestSFTPClient_UploadDownloadListMkdirRenameRemove(t *testing.T) {
+	rootDir := t.TempDir()
+	sclient := &SSHClient{Client: newTestSSHServer(t, rootDir)}
+	sftpClient, err := NewSFTPClient(sclient)
+	if err != nil {
+		t.Fatalf("NewSFTPClient: %v", err)
+	}
+	defer sftpClient.Close()
+
+	if err := sftpClient.Mkdir("dir/sub"); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+
+	n, err := sftpClient.Upload(bytes.NewReader([]byte("hello world")), "dir/hello.txt")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if n != int64(len("hello world")) {
+		t.Fatalf("Upload wrote %d bytes, want %d", n, len("hello world"))
+	}
+
+	infos, err := sftpClient.List("dir")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	names := map[string]bool{}
+	for _, fi := range infos {
+		names[fi.Name] = true
+	}
+	if !names["hello.txt"] || !names["sub"] {
+		t.Fatalf("expected dir to contain hello.txt and sub, got %v", infos)
+	}
+
+	var buf bytes.Buffer
+	if _, err := sftpClient.Download("dir/hello.txt", &buf); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if buf.String() != "hello world" {
+		t.Fatalf("Download got %q, want %q", buf.String(), "hello world")
+	}
+
+	sum, err := sftpClient.Sha256Sum("dir/hello.txt")
+	if err != nil {
+		t.Fatalf("Sha256Sum: %v", err)
+	}
+	want := sha256.Sum256([]byte("hello world"))
+	if sum != fmt.Sprintf("%x", want) {
+		t.Fatalf("Sha256Sum = %q, want %q", sum, fmt.Sprintf("%x", want))
+	}
+
+	if err := sftpClient.Rename("dir/hello.txt", "dir/renamed.txt"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := sftpClient.Download("dir/hello.txt", &buf); err == nil {
+		t.Fatal("expected the old path to be gone after Rename")
+	}
+
+	if err := sftpClient.Remove("dir/renamed.txt"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := sftpClient.Download("dir/renamed.txt", &buf); err == nil {
+		t.Fatal("expected the file to be gone after Remove")
+	}
+}
+
+func TestHandleSFTPFrame_ChunkedUploadLifecycle(t *testing.T) {
+	rootDir := t.TempDir()
+	sclient := &SSHClient{Client: newTestSSHServer(t, rootDir)}
+	ws, cleanup := newTestWSConn(t)
+	defer cleanup()
+
+	frame := func(typ, path, data string) []byte {
+		b, _ := json.Marshal(struct {
+			Type string `json:"type"`
+			Path string `json:"path"`
+			Data string `json:"data,omitempty"`
+		}{Type: typ, Path: path, Data: data})
+		return b
+	}
+
+	if err := sclient.handleSFTPFrame(frame("sftp-upload-start", "chunked.txt", ""), ws); err != nil {
+		t.Fatalf("upload-start: %v", err)
+	}
+	if _, ok := sclient.uploads["chunked.txt"]; !ok {
+		t.Fatal("expected an open file handle to be tracked after upload-start")
+	}
+
+	chunk1 := base64.StdEncoding.EncodeToString([]byte("hello "))
+	chunk2 := base64.StdEncoding.EncodeToString([]byte("world"))
+	if err := sclient.handleSFTPFrame(frame("sftp-upload-chunk", "chunked.txt", chunk1), ws); err != nil {
+		t.Fatalf("upload-chunk 1: %v", err)
+	}
+	if err := sclient.handleSFTPFrame(frame("sftp-upload-chunk", "chunked.txt", chunk2), ws); err != nil {
+		t.Fatalf("upload-chunk 2: %v", err)
+	}
+	if err := sclient.handleSFTPFrame(frame("sftp-upload-end", "chunked.txt", ""), ws); err != nil {
+		t.Fatalf("upload-end: %v", err)
+	}
+	if _, ok := sclient.uploads["chunked.txt"]; ok {
+		t.Fatal("expected the upload handle to be removed after upload-end")
+	}
+
+	got, err := os.ReadFile(filepath.Join(rootDir, "chunked.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Fatalf("chunked upload produced %q, want %q (each chunk must append, not truncate)", got, "hello world")
+	}
+
+	// a second upload-start on the same path must truncate, not append to, a previous write
+	if err := sclient.handleSFTPFrame(frame("sftp-upload-start", "chunked.txt", ""), ws); err != nil {
+		t.Fatalf("upload-start (overwrite): %v", err)
+	}
+	shortChunk := base64.StdEncoding.EncodeToString([]byte("hi"))
+	if err := sclient.handleSFTPFrame(frame("sftp-upload-chunk", "chunked.txt", shortChunk), ws); err != nil {
+		t.Fatalf("upload-chunk (overwrite): %v", err)
+	}
+	if err := sclient.handleSFTPFrame(frame("sftp-upload-end", "chunked.txt", ""), ws); err != nil {
+		t.Fatalf("upload-end (overwrite): %v", err)
+	}
+	got, err = os.ReadFile(filepath.Join(rootDir, "chunked.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "hi" {
+		t.Fatalf("second upload-start should overwrite the file, got %q, want %q", got, "hi")
+	}
+
+	sclient.CloseSFTP()
+	if sclient.sftpClient != nil {
+		t.Fatal("expected CloseSFTP to clear the cached sftp session")
+	}
+}
+
+func TestHandleSFTPFrame_Ls(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(rootDir, "a.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	sclient := &SSHClient{Client: newTestSSHServer(t, rootDir)}
+	ws, cleanup := newTestWSConn(t)
+	defer cleanup()
+
+	frame, _ := json.Marshal(struct {
+		Type string `json:"type"`
+		Path string `json:"path"`
+	}{Type: "sftp-ls", Path: "."})
+	if err := sclient.handleSFTPFrame(frame, ws); err != nil {
+		t.Fatalf("sftp-ls: %v", err)
+	}
+	sclient.CloseSFTP()
+}
@@ -1,14 +1,17 @@
 package core
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"log"
 	"net"
-	"strconv"
+	"os"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -31,6 +34,52 @@ func DecodedMsgToSSHClient(sshInfo string) (SSHClient, error) {
 	return client, nil
 }
 
+// buildAuthMethods 根据SSHClient上配置的凭据组装ssh.AuthMethod列表
+// 私钥和agent优先于密码,多种方式可以同时提供,由服务端按顺序尝试
+func buildAuthMethods(sclient *SSHClient) ([]ssh.AuthMethod, error) {
+	auth := make([]ssh.AuthMethod, 0)
+
+	switch {
+	case len(sclient.PrivateKey) > 0 || sclient.PrivateKeyPath != "":
+		keyBytes := sclient.PrivateKey
+		if len(keyBytes) == 0 {
+			b, err := os.ReadFile(sclient.PrivateKeyPath)
+			if err != nil {
+				return nil, err
+			}
+			keyBytes = b
+		}
+		var (
+			signer ssh.Signer
+			err    error
+		)
+		if sclient.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(keyBytes, []byte(sclient.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(keyBytes)
+		}
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if sclient.UseAgent {
+		auth = append(auth, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+			if err != nil {
+				return nil, err
+			}
+			return agent.NewClient(conn).Signers()
+		}))
+	}
+
+	if sclient.Password != "" || len(auth) == 0 {
+		auth = append(auth, ssh.Password(sclient.Password))
+	}
+	return auth, nil
+}
+
 // GenerateClient 创建ssh客户端
 func (sclient *SSHClient) GenerateClient() error {
 	var (
@@ -41,19 +90,18 @@ func (sclient *SSHClient) GenerateClient() error {
 		config       ssh.Config
 		err          error
 	)
-	auth = make([]ssh.AuthMethod, 0)
-	auth = append(auth, ssh.Password(sclient.Password))
+	if auth, err = buildAuthMethods(sclient); err != nil {
+		return err
+	}
 	config = ssh.Config{
 		Ciphers: []string{"aes128-ctr", "aes192-ctr", "aes256-ctr", "aes128-gcm@openssh.com", "arcfour256", "arcfour128", "aes128-cbc", "3des-cbc", "aes192-cbc", "aes256-cbc"},
 	}
 	clientConfig = &ssh.ClientConfig{
-		User:    sclient.Username,
-		Auth:    auth,
-		Timeout: 5 * time.Second,
-		Config:  config,
-		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		},
+		User:            sclient.Username,
+		Auth:            auth,
+		Timeout:         5 * time.Second,
+		Config:          config,
+		HostKeyCallback: sclient.hostKeyCallback(),
 	}
 	addr = fmt.Sprintf("%s:%d", sclient.IPAddress, sclient.Port)
 	if client, err = ssh.Dial("tcp", addr, clientConfig); err != nil {
@@ -74,8 +122,13 @@ func (sclient *SSHClient) InitTerminal(rows, cols int) *SSHClient {
 	sclient.StdinPipe, _ = sshSession.StdinPipe()
 	comboWriter := new(wsBufferWriter)
 	//ssh.stdout and stderr will write output into comboWriter
-	sshSession.Stdout = comboWriter
-	sshSession.Stderr = comboWriter
+	if sclient.Recorder != nil {
+		sshSession.Stdout = &recorderTee{w: comboWriter, recorder: sclient.Recorder}
+		sshSession.Stderr = &recorderTee{w: comboWriter, recorder: sclient.Recorder}
+	} else {
+		sshSession.Stdout = comboWriter
+		sshSession.Stderr = comboWriter
+	}
 	modes := ssh.TerminalModes{
 		ssh.ECHO:          1,
 		ssh.TTY_OP_ISPEED: 14400,
@@ -107,7 +160,11 @@ func flushComboOutput(w *wsBufferWriter, wsConn *websocket.Conn) error {
 			}
 			bufStr = string(buf)
 		}
-		if err := wsConn.WriteMessage(websocket.TextMessage, []byte(bufStr)); err != nil {
+		msg, err := json.Marshal(WSMsg{Type: MsgTypeStdout, Data: bufStr})
+		if err != nil {
+			return err
+		}
+		if err := wsConn.WriteMessage(websocket.TextMessage, msg); err != nil {
 			return err
 		}
 		w.buffer.Reset()
@@ -121,30 +178,59 @@ func (sclient *SSHClient) Connect(ws *websocket.Conn, timeout time.Duration) {
 	//这里第一个协程获取用户的输入
 	go func() {
 		for {
-			// p为用户输入
+			// p为用户输入,统一按WSMsg信封解析
 			_, p, err := ws.ReadMessage()
 			if err != nil {
 				close(stopCh)
 				return
 			}
-			if string(p) == "ping" {
+			var msg WSMsg
+			if err := json.Unmarshal(p, &msg); err != nil {
+				log.Println(err)
 				continue
 			}
-			if strings.Contains(string(p), "resize") {
-				resizeSlice := strings.Split(string(p), ":")
-				rows, _ := strconv.Atoi(resizeSlice[1])
-				cols, _ := strconv.Atoi(resizeSlice[2])
-				err := sclient.Session.WindowChange(rows, cols)
-				if err != nil {
+			switch {
+			case msg.Type == MsgTypePing:
+				continue
+			case strings.HasPrefix(msg.Type, "sftp-"):
+				if err := sclient.handleSFTPFrame(p, ws); err != nil {
+					log.Println(err)
+				}
+				continue
+			case msg.Type == MsgTypeResize:
+				if err := sclient.Session.WindowChange(msg.Rows, msg.Cols); err != nil {
 					log.Println(err)
 					return
 				}
+				if sclient.Recorder != nil {
+					if err := sclient.Recorder.Resize(msg.Rows, msg.Cols); err != nil {
+						log.Println(err)
+					}
+				}
 				continue
-			}
-			_, err = sclient.StdinPipe.Write(p)
-			if err != nil {
+			case msg.Type == MsgTypeClose:
 				close(stopCh)
 				return
+			case msg.Type == MsgTypeCmd:
+				data, err := base64.StdEncoding.DecodeString(msg.Data)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				if sclient.Filter != nil {
+					data = sclient.feedCmdInput(data, ws)
+				}
+				if sclient.Recorder != nil {
+					if err := sclient.Recorder.WriteInput(data); err != nil {
+						log.Println(err)
+					}
+				}
+				if _, err := sclient.StdinPipe.Write(data); err != nil {
+					close(stopCh)
+					return
+				}
+			default:
+				log.Println("未知的ws消息类型:", msg.Type)
 			}
 		}
 	}()
@@ -153,6 +239,7 @@ func (sclient *SSHClient) Connect(ws *websocket.Conn, timeout time.Duration) {
 	go func() {
 		defer func() {
 			ws.Close()
+			sclient.CloseSFTP()
 			if sclient.Session != nil {
 				sclient.ComboOutput = nil
 				sclient.StdinPipe.Close()
@@ -178,7 +265,8 @@ func (sclient *SSHClient) Connect(ws *websocket.Conn, timeout time.Duration) {
 			case <-stopCh:
 				return
 			case <-stopTimer.C:
-				ws.WriteMessage(1, []byte("\033[33m已超时关闭连接!\033[0m"))
+				timeoutMsg, _ := json.Marshal(WSMsg{Type: MsgTypeWarn, Data: "\033[33m已超时关闭连接!\033[0m"})
+				ws.WriteMessage(websocket.TextMessage, timeoutMsg)
 				return
 			case <-t.C:
 				if err := flushComboOutput(sclient.ComboOutput, ws); err != nil {
@@ -195,37 +283,67 @@ func (sclient *SSHClient) Connect(ws *websocket.Conn, timeout time.Duration) {
 	}()
 }
 
-// ExecRemoteCommand 执行远程命令
-func (sclient *SSHClient) ExecRemoteCommand(command string) (string, error) {
+// ExecRemoteCommand 执行远程命令,stdout/stderr分开返回,exitCode取自*ssh.ExitError。
+// ctx取消时会中断正在进行的dial/session,供MultiExec的worker池并发调用并独立判定每台主机的执行结果
+func (sclient *SSHClient) ExecRemoteCommand(ctx context.Context, command string) (stdout string, stderr string, exitCode int, err error) {
 	//创建ssh登陆配置
 	config := &ssh.ClientConfig{
 		Timeout:         time.Second, //ssh 连接time out 时间一秒钟, 如果ssh验证错误 会在一秒内返回
 		User:            sclient.Username,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //这个可以， 但是不够安全
+		HostKeyCallback: sclient.hostKeyCallback(),
+	}
+	auth, err := buildAuthMethods(sclient)
+	if err != nil {
+		return "", "", -1, err
 	}
-	config.Auth = []ssh.AuthMethod{ssh.Password(sclient.Password)}
+	config.Auth = auth
 
-	//dial 获取ssh client
+	//dial 获取ssh client,用DialContext保证ctx取消时不会卡在握手上
 	addr := fmt.Sprintf("%s:%d", sclient.IPAddress, sclient.Port)
-	sshClient, err := ssh.Dial("tcp", addr, config)
+	dialer := net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		fmt.Println("创建ssh client 失败: ", err)
+		return "", "", -1, err
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
 	if err != nil {
+		conn.Close()
 		fmt.Println("创建ssh client 失败: ", err)
-		return "", err
+		return "", "", -1, err
 	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
 	defer sshClient.Close()
 
 	//创建ssh-session
 	session, err := sshClient.NewSession()
 	if err != nil {
 		fmt.Println("创建ssh session 失败: ", err)
-		return "", err
+		return "", "", -1, err
 	}
 	defer session.Close()
-	//执行远程命令
-	combo, err := session.CombinedOutput(command)
-	if err != nil {
-		fmt.Println("远程执行cmd 失败: ", err)
-		return "", err
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	session.Stdout = &stdoutBuf
+	session.Stderr = &stderrBuf
+
+	//执行远程命令,ctx取消时关闭session以中断正在运行的命令
+	runErr := make(chan error, 1)
+	go func() { runErr <- session.Run(command) }()
+
+	select {
+	case <-ctx.Done():
+		session.Close()
+		<-runErr
+		return stdoutBuf.String(), stderrBuf.String(), -1, ctx.Err()
+	case err := <-runErr:
+		if err != nil {
+			if exitErr, ok := err.(*ssh.ExitError); ok {
+				return stdoutBuf.String(), stderrBuf.String(), exitErr.ExitStatus(), nil
+			}
+			fmt.Println("远程执行cmd 失败: ", err)
+			return stdoutBuf.String(), stderrBuf.String(), -1, err
+		}
+		return stdoutBuf.String(), stderrBuf.String(), 0, nil
 	}
-	return string(combo), nil
 }
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func generateTestRSAKeyPEM(t *testing.T, passphrase string) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if passphrase != "" {
+		//lint:ignore SA1019 only used to build an encrypted PEM fixture for ParsePrivateKeyWithPassphrase
+		encBlock, err := x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte(passphrase), x509.PEMCipherAES256)
+		if err != nil {
+			t.Fatalf("EncryptPEMBlock: %v", err)
+		}
+		block = encBlock
+	}
+	return pem.EncodeToMemory(block)
+}
+
+func TestBuildAuthMethods_PasswordOnly(t *testing.T) {
+	sclient := &SSHClient{Password: "hunter2"}
+	auth, err := buildAuthMethods(sclient)
+	if err != nil {
+		t.Fatalf("buildAuthMethods: %v", err)
+	}
+	if len(auth) != 1 {
+		t.Fatalf("expected exactly one auth method for password-only config, got %d", len(auth))
+	}
+}
+
+func TestBuildAuthMethods_EmptyCredentialsStillTriesPassword(t *testing.T) {
+	sclient := &SSHClient{}
+	auth, err := buildAuthMethods(sclient)
+	if err != nil {
+		t.Fatalf("buildAuthMethods: %v", err)
+	}
+	if len(auth) != 1 {
+		t.Fatalf("expected a fallback empty-password auth method when nothing else is configured, got %d", len(auth))
+	}
+}
+
+func TestBuildAuthMethods_PrivateKeyBytes(t *testing.T) {
+	sclient := &SSHClient{PrivateKey: generateTestRSAKeyPEM(t, "")}
+	auth, err := buildAuthMethods(sclient)
+	if err != nil {
+		t.Fatalf("buildAuthMethods: %v", err)
+	}
+	if len(auth) != 1 {
+		t.Fatalf("expected exactly one auth method for a bare private key, got %d", len(auth))
+	}
+}
+
+func TestBuildAuthMethods_PrivateKeyPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "id_rsa")
+	if err := os.WriteFile(path, generateTestRSAKeyPEM(t, ""), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	sclient := &SSHClient{PrivateKeyPath: path}
+	auth, err := buildAuthMethods(sclient)
+	if err != nil {
+		t.Fatalf("buildAuthMethods: %v", err)
+	}
+	if len(auth) != 1 {
+		t.Fatalf("expected exactly one auth method when loading a key from PrivateKeyPath, got %d", len(auth))
+	}
+}
+
+func TestBuildAuthMethods_PrivateKeyPathMissing(t *testing.T) {
+	sclient := &SSHClient{PrivateKeyPath: filepath.Join(t.TempDir(), "does-not-exist")}
+	if _, err := buildAuthMethods(sclient); err == nil {
+		t.Fatal("expected an error when PrivateKeyPath does not exist")
+	}
+}
+
+func TestBuildAuthMethods_PassphraseProtectedKey(t *testing.T) {
+	keyPEM := generateTestRSAKeyPEM(t, "s3cret")
+
+	sclient := &SSHClient{PrivateKey: keyPEM, Passphrase: "s3cret"}
+	if _, err := buildAuthMethods(sclient); err != nil {
+		t.Fatalf("buildAuthMethods with correct passphrase: %v", err)
+	}
+
+	sclient = &SSHClient{PrivateKey: keyPEM, Passphrase: "wrong"}
+	if _, err := buildAuthMethods(sclient); err == nil {
+		t.Fatal("expected an error when the passphrase does not match")
+	}
+}
+
+func TestBuildAuthMethods_KeyTakesPriorityButPasswordStillOffered(t *testing.T) {
+	sclient := &SSHClient{PrivateKey: generateTestRSAKeyPEM(t, ""), Password: "hunter2"}
+	auth, err := buildAuthMethods(sclient)
+	if err != nil {
+		t.Fatalf("buildAuthMethods: %v", err)
+	}
+	if len(auth) != 2 {
+		t.Fatalf("expected both the key and the password to be offered as auth methods, got %d", len(auth))
+	}
+}